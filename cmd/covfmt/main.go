@@ -0,0 +1,343 @@
+// Command covfmt converts go test coverage profiles into LCOV or Cobertura
+// reports.
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jandelgado/covfmt/lcov"
+	"github.com/jandelgado/covfmt/profile"
+)
+
+// Emitter writes a profile, in some output format, to w.
+type Emitter interface {
+	Emit(p *profile.Profile, w io.Writer) error
+}
+
+// lcovEmitter writes the profile as an LCOV tracefile.
+type lcovEmitter struct {
+	moduleRoot string
+}
+
+func (e lcovEmitter) Emit(p *profile.Profile, w io.Writer) error {
+	return lcov.Write(w, p, lcov.Options{
+		IncludeFunctions: true,
+		IncludeBranches:  true,
+		SourceRoot:       e.moduleRoot,
+	})
+}
+
+// coberturaEmitter writes the profile as a Cobertura XML report, with
+// packages grouped by source directory.
+type coberturaEmitter struct {
+	moduleRoot string
+}
+
+// The following types mirror the subset of the Cobertura XML schema
+// (http://cobertura.sourceforge.net/xml/coverage-04.dtd) that genhtml-style
+// consumers (Azure Pipelines, Jenkins, GitLab) actually read.
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LineRate        float64            `xml:"line-rate,attr"`
+	BranchRate      float64            `xml:"branch-rate,attr"`
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Timestamp       int64              `xml:"timestamp,attr"`
+	Version         string             `xml:"version,attr"`
+	Sources         []string           `xml:"sources>source"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   float64          `xml:"line-rate,attr"`
+	BranchRate float64          `xml:"branch-rate,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string            `xml:"name,attr"`
+	Filename   string            `xml:"filename,attr"`
+	LineRate   float64           `xml:"line-rate,attr"`
+	BranchRate float64           `xml:"branch-rate,attr"`
+	Methods    []coberturaMethod `xml:"methods>method"`
+	Lines      []coberturaLine   `xml:"lines>line"`
+}
+
+type coberturaMethod struct {
+	Name     string          `xml:"name,attr"`
+	LineRate float64         `xml:"line-rate,attr"`
+	Lines    []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int  `xml:"number,attr"`
+	Hits   int  `xml:"hits,attr"`
+	Branch bool `xml:"branch,attr"`
+}
+
+// rate returns hit/total as a Cobertura line-rate, or 0 for an empty set.
+func rate(hit, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(hit) / float64(total)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// buildCoberturaClass aggregates the blocks of a single source file into a
+// Cobertura <class>, filling in <methods> via the lcov package's function
+// coverage walk, line-rate from the lines the blocks span, and branch-rate
+// via the lcov package's branch coverage walk. sourceFile is the file's location on disk, used to parse
+// its AST; relFile is its path relative to the source root, the form
+// written out as Filename so reports stay portable across machines.
+// buildCoberturaClass also returns the class's raw branch totals, so
+// callers can roll them up into the enclosing <package> and <coverage>
+// rates without re-parsing the source.
+func buildCoberturaClass(name, sourceFile, relFile string, blocks []*profile.Block) (coberturaClass, int, int) {
+	hits := map[int]int{}
+	var lineNumbers []int
+	for _, b := range blocks {
+		for i := b.StartLine; i <= b.EndLine; i++ {
+			if _, seen := hits[i]; !seen {
+				lineNumbers = append(lineNumbers, i)
+			}
+			if b.Covered > hits[i] {
+				hits[i] = b.Covered
+			}
+		}
+	}
+	sort.Ints(lineNumbers)
+
+	lines := make([]coberturaLine, 0, len(lineNumbers))
+	linesHit := 0
+	for _, n := range lineNumbers {
+		if hits[n] > 0 {
+			linesHit++
+		}
+		lines = append(lines, coberturaLine{Number: n, Hits: hits[n]})
+	}
+
+	var methods []coberturaMethod
+	if coverage, ok := lcov.FunctionCoverage(sourceFile, blocks); ok {
+		for _, fn := range coverage {
+			methods = append(methods, coberturaMethod{
+				Name:     fn.Name,
+				LineRate: rate(boolToInt(fn.Hits > 0), 1),
+				Lines:    []coberturaLine{{Number: fn.StartLine, Hits: fn.Hits}},
+			})
+		}
+	}
+
+	branchTotal, branchHit, _ := lcov.BranchCoverage(sourceFile, blocks)
+
+	class := coberturaClass{
+		Name:       name,
+		Filename:   relFile,
+		LineRate:   rate(linesHit, len(lines)),
+		BranchRate: rate(branchHit, branchTotal),
+		Methods:    methods,
+		Lines:      lines,
+	}
+	return class, branchTotal, branchHit
+}
+
+func (e coberturaEmitter) Emit(p *profile.Profile, w io.Writer) error {
+	resolve := lcov.DefaultResolver(e.moduleRoot)
+
+	filesByDir := map[string][]string{}
+	sourcePaths := map[string]string{}
+	relPaths := map[string]string{}
+	sourceRoot := "."
+	for file := range p.Blocks {
+		sourceFile, err := resolve(file)
+		if err != nil {
+			continue
+		}
+		if root, ok := lcov.SourceRoot(sourceFile); ok {
+			sourceRoot = root
+		}
+		relFile := lcov.SourceFileName(sourceFile)
+		dir := filepath.Dir(relFile)
+		filesByDir[dir] = append(filesByDir[dir], file)
+		sourcePaths[file] = sourceFile
+		relPaths[file] = relFile
+	}
+
+	var dirs []string
+	for dir := range filesByDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var packages []coberturaPackage
+	totalLines, totalLinesHit := 0, 0
+	totalBranches, totalBranchesHit := 0, 0
+	for _, dir := range dirs {
+		files := filesByDir[dir]
+		sort.Strings(files)
+
+		var classes []coberturaClass
+		pkgLines, pkgLinesHit := 0, 0
+		pkgBranches, pkgBranchesHit := 0, 0
+		for _, file := range files {
+			sourceFile, relFile := sourcePaths[file], relPaths[file]
+			class, branchTotal, branchHit := buildCoberturaClass(filepath.Base(relFile), sourceFile, relFile, p.Blocks[file])
+			pkgLines += len(class.Lines)
+			for _, l := range class.Lines {
+				if l.Hits > 0 {
+					pkgLinesHit++
+				}
+			}
+			pkgBranches += branchTotal
+			pkgBranchesHit += branchHit
+			classes = append(classes, class)
+		}
+
+		packages = append(packages, coberturaPackage{
+			Name:       dir,
+			LineRate:   rate(pkgLinesHit, pkgLines),
+			BranchRate: rate(pkgBranchesHit, pkgBranches),
+			Classes:    classes,
+		})
+		totalLines += pkgLines
+		totalLinesHit += pkgLinesHit
+		totalBranches += pkgBranches
+		totalBranchesHit += pkgBranchesHit
+	}
+
+	doc := coberturaCoverage{
+		LineRate:        rate(totalLinesHit, totalLines),
+		BranchRate:      rate(totalBranchesHit, totalBranches),
+		LinesCovered:    totalLinesHit,
+		LinesValid:      totalLines,
+		BranchesCovered: totalBranchesHit,
+		BranchesValid:   totalBranches,
+		Timestamp:       time.Now().Unix(),
+		Version:         "covfmt",
+		Sources:         []string{sourceRoot},
+		Packages:        packages,
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	bw.WriteString(`<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">` + "\n")
+	enc := xml.NewEncoder(bw)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// emitterForFormat returns the Emitter registered for the given -format
+// value.
+func emitterForFormat(format, moduleRoot string) (Emitter, error) {
+	switch format {
+	case "", "lcov":
+		return lcovEmitter{moduleRoot: moduleRoot}, nil
+	case "cobertura":
+		return coberturaEmitter{moduleRoot: moduleRoot}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want %q or %q)", format, "lcov", "cobertura")
+	}
+}
+
+// profileFlag collects one or more profile paths supplied either as a
+// comma-separated list or via repeated -coverin flags.
+type profileFlag []string
+
+func (p *profileFlag) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *profileFlag) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*p = append(*p, part)
+		}
+	}
+	return nil
+}
+
+func main() {
+	var infileNames profileFlag
+	flag.Var(&infileNames, "coverin", "go cover profile(s) to read; comma separated or flag repeated (default: stdin)")
+	outfileName := flag.String("lcovout", "", "If supplied, write the report to this file (default: stdout)")
+	moduleRootName := flag.String("module-root", "", "override the Go module root used to resolve coverage file paths (default: auto-detect go.mod from the working directory)")
+	formatName := flag.String("format", "lcov", "output format: lcov or cobertura")
+
+	flag.Parse()
+	if len(flag.Args()) > 0 {
+		cmd := os.Args[0]
+		s := "Usage: %s [options]\n"
+		fmt.Fprintf(os.Stderr, s, cmd)
+		flag.PrintDefaults()
+		//	flag.Usage()
+		os.Exit(1)
+	}
+
+	outfile := os.Stdout
+	var err error
+	if *outfileName != "" {
+		outfile, err = os.Create(*outfileName)
+		if err != nil {
+			panic(err)
+		}
+		defer outfile.Close()
+	}
+
+	var profiles []*profile.Profile
+	if len(infileNames) == 0 {
+		p, err := profile.ParseProfile(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		profiles = append(profiles, p)
+	} else {
+		for _, name := range infileNames {
+			infile, err := os.Open(name)
+			if err != nil {
+				panic(err)
+			}
+			p, err := profile.ParseProfile(infile)
+			infile.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+			profiles = append(profiles, p)
+		}
+	}
+
+	merged, err := profile.MergeProfiles(profiles...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	emitter, err := emitterForFormat(*formatName, *moduleRootName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := emitter.Emit(merged, outfile); err != nil {
+		log.Fatal(err)
+	}
+}
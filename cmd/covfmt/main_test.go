@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/jandelgado/covfmt/profile"
+)
+
+// sampleProfile runs `go test -coverprofile` against the sample fixture
+// package and returns the resulting profile.
+func sampleProfile(t *testing.T) *profile.Profile {
+	t.Helper()
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found on PATH")
+	}
+
+	profPath := filepath.Join(t.TempDir(), "cover.out")
+	cmd := exec.Command(goBin, "test", "-coverprofile="+profPath, "./testdata/sample")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test ./testdata/sample: %v\n%s", err, out)
+	}
+
+	f, err := os.Open(profPath)
+	if err != nil {
+		t.Fatalf("open coverage profile: %v", err)
+	}
+	defer f.Close()
+
+	p, err := profile.ParseProfile(f)
+	if err != nil {
+		t.Fatalf("parse coverage profile: %v", err)
+	}
+	return p
+}
+
+// TestCoberturaEmit is a golden-output test for the Cobertura emitter: it
+// runs a real go test -coverprofile over a fixture with known, partial
+// coverage and checks the resulting XML tree. <source> and the timestamp
+// attribute are environment-dependent (the repo's absolute path and the
+// time Emit ran), so those are left unchecked; everything else is
+// determined by the fixture and must be exact.
+func TestCoberturaEmit(t *testing.T) {
+	p := sampleProfile(t)
+
+	var buf bytes.Buffer
+	if err := (coberturaEmitter{}).Emit(p, &buf); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+
+	var doc coberturaCoverage
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("unmarshal emitted XML: %v\n%s", err, buf.String())
+	}
+
+	if len(doc.Packages) != 1 {
+		t.Fatalf("got %d packages, want 1:\n%s", len(doc.Packages), buf.String())
+	}
+	pkg := doc.Packages[0]
+	if want := "cmd/covfmt/testdata/sample"; pkg.Name != want {
+		t.Errorf("package name = %q, want %q", pkg.Name, want)
+	}
+
+	if len(pkg.Classes) != 1 {
+		t.Fatalf("got %d classes, want 1", len(pkg.Classes))
+	}
+	class := pkg.Classes[0]
+	if want := "cmd/covfmt/testdata/sample/sample.go"; class.Filename != want {
+		t.Errorf("class filename = %q, want %q", class.Filename, want)
+	}
+
+	if len(class.Methods) != 1 || class.Methods[0].Name != "Classify" {
+		t.Fatalf("class methods = %+v, want a single Classify method", class.Methods)
+	}
+	if class.Methods[0].Lines[0].Hits == 0 {
+		t.Error("Classify was called by the fixture test, so it should show hits > 0")
+	}
+
+	// Classify has one if with no else: the if-body arm is taken, the
+	// implicit missing-else arm has no source position and can never be
+	// "taken", so the class's branch-rate is exactly 1 of 2.
+	if want := 0.5; class.BranchRate != want {
+		t.Errorf("class branch-rate = %v, want %v", class.BranchRate, want)
+	}
+	if doc.BranchRate != class.BranchRate {
+		t.Errorf("document branch-rate = %v, want it to match the lone class's %v", doc.BranchRate, class.BranchRate)
+	}
+	if doc.BranchesValid != 2 {
+		t.Errorf("branches-valid = %d, want 2", doc.BranchesValid)
+	}
+	if doc.BranchesCovered != 1 {
+		t.Errorf("branches-covered = %d, want 1", doc.BranchesCovered)
+	}
+
+	// go cover's blocks span the func signature through "return non-positive"
+	// (lines 6-10): the if-body's "return positive" is covered, the trailing
+	// "return non-positive" is not, so 4 of those 5 lines are hit.
+	if want := 4; doc.LinesCovered != want {
+		t.Errorf("lines-covered = %d, want %d", doc.LinesCovered, want)
+	}
+	if want := 5; doc.LinesValid != want {
+		t.Errorf("lines-valid = %d, want %d", doc.LinesValid, want)
+	}
+}
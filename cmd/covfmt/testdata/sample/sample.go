@@ -0,0 +1,11 @@
+// Package sample is a fixture used by main_test.go's golden-output test
+// for the Cobertura emitter.
+package sample
+
+// Classify reports whether n is positive.
+func Classify(n int) string {
+	if n > 0 {
+		return "positive"
+	}
+	return "non-positive"
+}
@@ -0,0 +1,9 @@
+package sample
+
+import "testing"
+
+// Only the positive branch is exercised, so the emitted Cobertura report
+// has a known, partial line and branch rate for main_test.go to check.
+func TestClassify(t *testing.T) {
+	Classify(5)
+}
@@ -0,0 +1,633 @@
+// Package lcov writes Go coverage profiles as LCOV tracefiles, the format
+// consumed by tools like genhtml and Coveralls.
+package lcov
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jandelgado/covfmt/profile"
+)
+
+var vcsDirs = []string{".git", ".hg", ".bzr", ".svn"}
+
+// Options controls what Write emits and how it locates source files.
+type Options struct {
+	// IncludeFunctions adds FN/FNDA/FNF/FNH records, derived by parsing
+	// each source file's AST.
+	IncludeFunctions bool
+	// IncludeBranches adds BRDA/BRF/BRH records, derived by parsing each
+	// source file's AST.
+	IncludeBranches bool
+	// SourceRoot overrides auto-detection of the Go module root used by
+	// the default Resolver. Ignored if Resolver is set.
+	SourceRoot string
+	// Resolver maps a profile's declared file path (e.g.
+	// "github.com/user/repo/pkg/file.go") to its location on disk. If
+	// nil, DefaultResolver(SourceRoot) is used.
+	Resolver func(string) (string, error)
+}
+
+// Write emits p as an LCOV tracefile to w. Files that Options.Resolver (or
+// the default resolver) can't locate on disk are silently skipped, since
+// genhtml/Coveralls can't make use of an SF: entry that doesn't point
+// anywhere.
+func Write(w io.Writer, p *profile.Profile, opts Options) error {
+	resolve := opts.Resolver
+	if resolve == nil {
+		resolve = DefaultResolver(opts.SourceRoot)
+	}
+
+	bw := bufio.NewWriter(w)
+	for file, blocks := range p.Blocks {
+		diskPath, err := resolve(file)
+		if err != nil {
+			continue
+		}
+		writeRecord(SourceFileName(diskPath), diskPath, blocks, opts, bw)
+	}
+	return bw.Flush()
+}
+
+func writeRecord(sfName, diskPath string, blocks []*profile.Block, opts Options, w *bufio.Writer) {
+	w.WriteString("TN:\n")
+	w.WriteString("SF:" + sfName + "\n")
+
+	if opts.IncludeFunctions {
+		writeFuncRecords(diskPath, blocks, w)
+	}
+
+	total := 0
+	covered := 0
+	for _, b := range blocks {
+		for i := b.StartLine; i <= b.EndLine; i++ {
+			total++
+			if b.Covered > 0 {
+				covered++
+			}
+			w.WriteString("DA:" + strconv.Itoa(i) + "," + strconv.Itoa(b.Covered) + "\n")
+		}
+	}
+	w.WriteString("LF:" + strconv.Itoa(total) + "\n")
+	w.WriteString("LH:" + strconv.Itoa(covered) + "\n")
+
+	if opts.IncludeBranches {
+		writeBranchRecords(diskPath, blocks, w)
+	}
+
+	w.WriteString("end_of_record\n")
+}
+
+// branchArm is one arm of a branching construct: an if's body or else, or a
+// switch/select's case (including an implicit missing else/default).
+type branchArm struct {
+	blockID  int
+	branchID int
+	line     int
+	col      int
+	hasPos   bool // false for an implicit arm (missing else/default) with no source position
+}
+
+// elsePos returns the position go cover assigns to the start of an if's
+// else arm: one byte before the else arm's own AST node, whether that's a
+// plain "else { ... }" block or a nested "else if" statement. There is no
+// AST node for the "else" keyword itself to point at, and cover's block
+// starts immediately after it, which lands one byte short of both a
+// BlockStmt's Lbrace and a nested IfStmt's "if" keyword.
+func elsePos(elseStmt ast.Stmt) token.Pos {
+	return elseStmt.Pos() - 1
+}
+
+// collectBranches walks the AST of f and returns every branch arm of its
+// if/switch/select statements.
+//
+// Short-circuit && / || operands are deliberately not treated as branches
+// here: go test -coverprofile only instruments at statement-block
+// granularity, so there is no profile block to attribute either operand's
+// coverage to. Reporting them would produce BRDA records that can never
+// show as taken, permanently depressing branch coverage rather than
+// measuring it.
+func collectBranches(fset *token.FileSet, f *ast.File) []branchArm {
+	var arms []branchArm
+	blockID := 0
+	pos := func(p token.Pos) (int, int) {
+		position := fset.Position(p)
+		return position.Line, position.Column
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.IfStmt:
+			id := blockID
+			blockID++
+			line, col := pos(s.Body.Pos())
+			arms = append(arms, branchArm{blockID: id, branchID: 0, line: line, col: col, hasPos: true})
+			if s.Else != nil {
+				line, col := pos(elsePos(s.Else))
+				arms = append(arms, branchArm{blockID: id, branchID: 1, line: line, col: col, hasPos: true})
+			} else {
+				line, _ := pos(s.Pos())
+				arms = append(arms, branchArm{blockID: id, branchID: 1, line: line})
+			}
+
+		case *ast.SwitchStmt:
+			arms = append(arms, caseClauseArms(blockID, s.Pos(), s.Body.List, fset)...)
+			blockID++
+
+		case *ast.TypeSwitchStmt:
+			arms = append(arms, caseClauseArms(blockID, s.Pos(), s.Body.List, fset)...)
+			blockID++
+
+		case *ast.SelectStmt:
+			id := blockID
+			blockID++
+			hasDefault := false
+			for i, c := range s.Body.List {
+				cc := c.(*ast.CommClause)
+				if cc.Comm == nil {
+					hasDefault = true
+				}
+				line, col := commClauseFirstPos(cc, fset)
+				arms = append(arms, branchArm{blockID: id, branchID: i, line: line, col: col, hasPos: true})
+			}
+			if !hasDefault {
+				line, _ := pos(s.Pos())
+				arms = append(arms, branchArm{blockID: id, branchID: len(s.Body.List), line: line})
+			}
+		}
+		return true
+	})
+	return arms
+}
+
+// caseClauseArms builds the branch arms of a switch/type switch statement,
+// adding an implicit arm for a missing "default" case.
+func caseClauseArms(blockID int, switchPos token.Pos, clauses []ast.Stmt, fset *token.FileSet) []branchArm {
+	var arms []branchArm
+	hasDefault := false
+	for i, c := range clauses {
+		cc := c.(*ast.CaseClause)
+		if cc.List == nil {
+			hasDefault = true
+		}
+		line, col := caseClauseFirstPos(cc, fset)
+		arms = append(arms, branchArm{blockID: blockID, branchID: i, line: line, col: col, hasPos: true})
+	}
+	if !hasDefault {
+		position := fset.Position(switchPos)
+		arms = append(arms, branchArm{blockID: blockID, branchID: len(clauses), line: position.Line})
+	}
+	return arms
+}
+
+// caseClauseFirstPos returns the position go cover assigns to the start of
+// a case clause's body: one byte past its colon, which is where the case's
+// counter block begins regardless of whether the clause has any
+// statements.
+func caseClauseFirstPos(cc *ast.CaseClause, fset *token.FileSet) (int, int) {
+	position := fset.Position(cc.Colon + 1)
+	return position.Line, position.Column
+}
+
+// commClauseFirstPos is caseClauseFirstPos for select's comm clauses.
+func commClauseFirstPos(cc *ast.CommClause, fset *token.FileSet) (int, int) {
+	position := fset.Position(cc.Colon + 1)
+	return position.Line, position.Column
+}
+
+// blockAt returns the block starting at exactly line.col, if any.
+func blockAt(blocks []*profile.Block, line, col int) (*profile.Block, bool) {
+	for _, b := range blocks {
+		if b.StartLine == line && b.StartChar == col {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// branchMatch pairs a branch arm with the covered count of the profile
+// block it matched, if any. taken is -1 if the arm has no matching block
+// (or no source position at all, e.g. a missing else).
+type branchMatch struct {
+	arm   branchArm
+	taken int
+}
+
+// matchBranches parses sourceFile, collects its branch arms and matches
+// each against the profile block starting at the same position. It
+// reports ok=false if the source can't be parsed.
+func matchBranches(sourceFile string, blocks []*profile.Block) ([]branchMatch, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, sourceFile, nil, 0)
+	if err != nil {
+		return nil, false
+	}
+
+	arms := collectBranches(fset, f)
+	matches := make([]branchMatch, 0, len(arms))
+	for _, arm := range arms {
+		taken := -1
+		if arm.hasPos {
+			if b, ok := blockAt(blocks, arm.line, arm.col); ok {
+				taken = b.Covered
+			}
+		}
+		matches = append(matches, branchMatch{arm: arm, taken: taken})
+	}
+	return matches, true
+}
+
+// BranchCoverage reports the total number of branch arms found in
+// sourceFile (via the same AST walk used for LCOV's BRDA records) and how
+// many of them were taken at least once, according to blocks. It reports
+// ok=false if the source can't be parsed, so callers can fall back to
+// omitting branch data.
+func BranchCoverage(sourceFile string, blocks []*profile.Block) (total, hit int, ok bool) {
+	matches, ok := matchBranches(sourceFile, blocks)
+	if !ok {
+		return 0, 0, false
+	}
+	for _, m := range matches {
+		if m.taken > 0 {
+			hit++
+		}
+	}
+	return len(matches), hit, true
+}
+
+// writeBranchRecords emits the BRDA/BRF/BRH sections for sourceFile,
+// reusing the same arm/block matching as BranchCoverage. An unmatched arm
+// is reported as "-", LCOV's not-taken/no-data marker. It degrades to
+// zero branches if the source can't be parsed.
+func writeBranchRecords(sourceFile string, blocks []*profile.Block, w *bufio.Writer) {
+	matches, ok := matchBranches(sourceFile, blocks)
+	if !ok {
+		w.WriteString("BRF:0\n")
+		w.WriteString("BRH:0\n")
+		return
+	}
+
+	hit := 0
+	for _, m := range matches {
+		taken := "-"
+		if m.taken >= 0 {
+			taken = strconv.Itoa(m.taken)
+			if m.taken > 0 {
+				hit++
+			}
+		}
+		w.WriteString(fmt.Sprintf("BRDA:%d,%d,%d,%s\n", m.arm.line, m.arm.blockID, m.arm.branchID, taken))
+	}
+
+	w.WriteString("BRF:" + strconv.Itoa(len(matches)) + "\n")
+	w.WriteString("BRH:" + strconv.Itoa(hit) + "\n")
+}
+
+// FuncCoverage describes one function's location and aggregated hit count.
+type FuncCoverage struct {
+	Name      string
+	StartLine int
+	Hits      int
+}
+
+// funcExtent describes the location of a function or method declaration,
+// as found by walking the AST of a source file.
+type funcExtent struct {
+	name      string
+	startLine int
+	startCol  int
+	endLine   int
+	endCol    int
+}
+
+// receiverName renders the receiver type of a method the way cmd/cover's
+// func.go does, e.g. "T" or "(*T)".
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			return "(*" + id.Name + ")"
+		}
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// findFuncs parses filePath and returns the extent of every function and
+// method declaration in it, in source order.
+func findFuncs(filePath string) ([]*funcExtent, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filePath, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var extents []*funcExtent
+	ast.Inspect(f, func(n ast.Node) bool {
+		decl, ok := n.(*ast.FuncDecl)
+		if !ok {
+			return true
+		}
+		name := decl.Name.Name
+		if decl.Recv != nil && len(decl.Recv.List) > 0 {
+			if r := receiverName(decl.Recv.List[0].Type); r != "" {
+				name = r + "." + name
+			}
+		}
+		start := fset.Position(decl.Pos())
+		end := fset.Position(decl.End())
+		extents = append(extents, &funcExtent{
+			name:      name,
+			startLine: start.Line,
+			startCol:  start.Column,
+			endLine:   end.Line,
+			endCol:    end.Column,
+		})
+		return true
+	})
+	return extents, nil
+}
+
+// blockInFunc reports whether b lies within the extent of fn.
+func blockInFunc(b *profile.Block, fn *funcExtent) bool {
+	if b.StartLine < fn.startLine || b.EndLine > fn.endLine {
+		return false
+	}
+	if b.StartLine == fn.startLine && b.StartChar < fn.startCol {
+		return false
+	}
+	if b.EndLine == fn.endLine && b.EndChar > fn.endCol {
+		return false
+	}
+	return true
+}
+
+// FunctionCoverage parses sourceFile and, for each function and method
+// declared in it, sums the covered counts of the blocks that fall within
+// its extent. It reports ok=false if the source can't be parsed, so
+// callers can fall back to omitting function data.
+func FunctionCoverage(sourceFile string, blocks []*profile.Block) ([]FuncCoverage, bool) {
+	funcs, err := findFuncs(sourceFile)
+	if err != nil {
+		return nil, false
+	}
+
+	coverage := make([]FuncCoverage, 0, len(funcs))
+	for _, fn := range funcs {
+		hits := 0
+		for _, b := range blocks {
+			if blockInFunc(b, fn) {
+				hits += b.Covered
+			}
+		}
+		coverage = append(coverage, FuncCoverage{Name: fn.name, StartLine: fn.startLine, Hits: hits})
+	}
+	return coverage, true
+}
+
+// writeFuncRecords emits the FN/FNDA/FNF/FNH sections for sourceFile. It is
+// a no-op if the source can't be parsed, so callers can keep emitting the
+// rest of the record as before.
+func writeFuncRecords(sourceFile string, blocks []*profile.Block, w *bufio.Writer) {
+	coverage, ok := FunctionCoverage(sourceFile, blocks)
+	if !ok {
+		return
+	}
+
+	hit := 0
+	for _, fn := range coverage {
+		if fn.Hits > 0 {
+			hit++
+		}
+		w.WriteString("FN:" + strconv.Itoa(fn.StartLine) + "," + fn.Name + "\n")
+		w.WriteString("FNDA:" + strconv.Itoa(fn.Hits) + "," + fn.Name + "\n")
+	}
+
+	w.WriteString("FNF:" + strconv.Itoa(len(coverage)) + "\n")
+	w.WriteString("FNH:" + strconv.Itoa(hit) + "\n")
+}
+
+// SourceRoot walks up from path (a file or directory on disk) looking for a
+// VCS directory (.git, .hg, .bzr, .svn), returning the directory that
+// contains it. It reports ok=false if none is found, e.g. because the
+// source tree isn't under version control.
+func SourceRoot(path string) (string, bool) {
+	for _, vcsdir := range vcsDirs {
+		if d, err := os.Stat(filepath.Join(path, vcsdir)); err == nil && d.IsDir() {
+			return path, true
+		}
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return "", false
+	}
+	return SourceRoot(parent)
+}
+
+// SourceFileName trims a source file's absolute path down to be relative to
+// its SourceRoot, the form Coveralls, genhtml and Cobertura consumers
+// expect. It returns name unchanged if no repository root can be found.
+func SourceFileName(name string) string {
+	dir, ok := SourceRoot(name)
+	if !ok {
+		return name
+	}
+	return strings.TrimPrefix(name, dir+string(os.PathSeparator))
+}
+
+// moduleInfo holds what we need from a go.mod file to resolve coverage
+// paths: the module's own import path, the directory it lives in, and any
+// local replace directives.
+type moduleInfo struct {
+	path    string
+	root    string
+	replace map[string]string
+}
+
+// findGoModDir walks up from dir looking for a go.mod file.
+func findGoModDir(dir string) (string, bool) {
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// stripComment trims a trailing "//" comment and surrounding whitespace
+// from a go.mod line.
+func stripComment(line string) string {
+	if i := strings.Index(line, "//"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+// parseReplaceLine parses a single "old [version] => new [version]" replace
+// directive and, if new is a local filesystem path, records it in info so
+// that coverage paths under old resolve to it.
+func parseReplaceLine(info *moduleInfo, line string, gomodDir string) {
+	parts := strings.SplitN(line, "=>", 2)
+	if len(parts) != 2 {
+		return
+	}
+	oldFields := strings.Fields(parts[0])
+	newFields := strings.Fields(parts[1])
+	if len(oldFields) == 0 || len(newFields) == 0 {
+		return
+	}
+	oldPath := oldFields[0]
+	newPath := newFields[0]
+	if !filepath.IsAbs(newPath) && !strings.HasPrefix(newPath, "./") && !strings.HasPrefix(newPath, "../") {
+		// Not a local replacement (e.g. a versioned module); we have no
+		// vendored copy to resolve it against, so leave it to build.Import.
+		return
+	}
+	if !filepath.IsAbs(newPath) {
+		newPath = filepath.Join(gomodDir, newPath)
+	}
+	info.replace[oldPath] = newPath
+}
+
+// parseGoMod reads the go.mod governing dir (walking up the tree to find
+// it) and extracts the module path and any local replace directives.
+// Everything else in the file (require, go, toolchain, ...) is irrelevant
+// to path resolution and is ignored.
+func parseGoMod(dir string) *moduleInfo {
+	gomodDir, ok := findGoModDir(dir)
+	if !ok {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(gomodDir, "go.mod"))
+	if err != nil {
+		return nil
+	}
+
+	info := &moduleInfo{root: gomodDir, replace: map[string]string{}}
+	inReplaceBlock := false
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := stripComment(raw)
+		if line == "" {
+			continue
+		}
+		switch {
+		case inReplaceBlock:
+			if line == ")" {
+				inReplaceBlock = false
+				continue
+			}
+			parseReplaceLine(info, line, gomodDir)
+		case strings.HasPrefix(line, "module "):
+			info.path = strings.TrimSpace(strings.TrimPrefix(line, "module "))
+		case line == "replace (":
+			inReplaceBlock = true
+		case strings.HasPrefix(line, "replace "):
+			parseReplaceLine(info, strings.TrimPrefix(line, "replace "), gomodDir)
+		}
+	}
+
+	if info.path == "" {
+		return nil
+	}
+	return info
+}
+
+// matchReplace finds the longest replace directive whose old path is a
+// prefix of candidate, returning the replacement directory and the
+// remaining path under it.
+func matchReplace(m *moduleInfo, candidate string) (string, string, bool) {
+	bestLen := -1
+	var real, rest string
+	for oldPath, newPath := range m.replace {
+		if candidate == oldPath && len(oldPath) > bestLen {
+			bestLen, real, rest = len(oldPath), newPath, ""
+			continue
+		}
+		prefix := oldPath + "/"
+		if strings.HasPrefix(candidate, prefix) && len(oldPath) > bestLen {
+			bestLen, real, rest = len(oldPath), newPath, strings.TrimPrefix(candidate, prefix)
+		}
+	}
+	if bestLen < 0 {
+		return "", "", false
+	}
+	return real, rest, true
+}
+
+// resolveModulePath rewrites file, a coverage path of the form
+// "<module path>/relative/file.go", to an on-disk path using mod,
+// honouring local replace directives. It reports false when mod is nil or
+// file isn't rooted at the module's path.
+func resolveModulePath(mod *moduleInfo, file string) (string, bool) {
+	if mod == nil {
+		return "", false
+	}
+	if real, rest, ok := matchReplace(mod, file); ok {
+		return filepath.Join(real, rest), true
+	}
+	if file == mod.path {
+		return mod.root, true
+	}
+	if strings.HasPrefix(file, mod.path+"/") {
+		return filepath.Join(mod.root, strings.TrimPrefix(file, mod.path+"/")), true
+	}
+	return "", false
+}
+
+type cacheResult struct {
+	dir string
+	err error
+}
+
+// DefaultResolver returns a resolver that rewrites coverage file paths
+// using the go.mod found at sourceRoot (or, if sourceRoot is empty, the
+// current working directory), falling back to GOPATH-style resolution via
+// go/build for repos that don't use modules.
+func DefaultResolver(sourceRoot string) func(string) (string, error) {
+	dir := sourceRoot
+	if dir == "" {
+		if wd, err := os.Getwd(); err == nil {
+			dir = wd
+		}
+	}
+	mod := parseGoMod(dir)
+	// pkgDirCache memoizes the (expensive) build.Import lookup per package
+	// directory; the file's own base name is always joined back on afterwards,
+	// so multiple files from the same package resolve independently.
+	pkgDirCache := map[string]cacheResult{}
+
+	return func(file string) (string, error) {
+		if resolved, ok := resolveModulePath(mod, file); ok {
+			return resolved, nil
+		}
+
+		fileDir, base := filepath.Split(file)
+		cached, ok := pkgDirCache[fileDir]
+		if !ok {
+			if pkg, err := build.Import(fileDir, ".", build.FindOnly); err != nil {
+				cached = cacheResult{"", fmt.Errorf("can't find %q: %v", file, err)}
+			} else {
+				cached = cacheResult{pkg.Dir, nil}
+			}
+			pkgDirCache[fileDir] = cached
+		}
+		if cached.err != nil {
+			return "", cached.err
+		}
+		return filepath.Join(cached.dir, base), nil
+	}
+}
@@ -0,0 +1,168 @@
+package lcov
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jandelgado/covfmt/profile"
+)
+
+// branchProfile runs `go test -coverprofile` against the branches fixture
+// package and returns the blocks recorded for branches.go.
+func branchProfile(t *testing.T) []*profile.Block {
+	t.Helper()
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found on PATH")
+	}
+
+	profPath := filepath.Join(t.TempDir(), "cover.out")
+	cmd := exec.Command(goBin, "test", "-coverprofile="+profPath, "./testdata/branches")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test ./testdata/branches: %v\n%s", err, out)
+	}
+
+	f, err := os.Open(profPath)
+	if err != nil {
+		t.Fatalf("open coverage profile: %v", err)
+	}
+	defer f.Close()
+
+	p, err := profile.ParseProfile(f)
+	if err != nil {
+		t.Fatalf("parse coverage profile: %v", err)
+	}
+
+	for file, blocks := range p.Blocks {
+		if strings.HasSuffix(file, "testdata/branches/branches.go") {
+			return blocks
+		}
+	}
+	t.Fatalf("no blocks found for branches.go in profile: %v", p.Blocks)
+	return nil
+}
+
+// arm looks up the branch arm with the given blockID/branchID, failing the
+// test if it isn't present.
+func arm(t *testing.T, arms []branchArm, blockID, branchID int) branchArm {
+	t.Helper()
+	for _, a := range arms {
+		if a.blockID == blockID && a.branchID == branchID {
+			return a
+		}
+	}
+	t.Fatalf("no arm with blockID=%d branchID=%d in %v", blockID, branchID, arms)
+	return branchArm{}
+}
+
+// taken reports whether a's position matches a block in blocks with a
+// positive covered count.
+func taken(arms []branchArm, blocks []*profile.Block, a branchArm) (covered bool, hasBlock bool) {
+	if !a.hasPos {
+		return false, false
+	}
+	b, ok := blockAt(blocks, a.line, a.col)
+	if !ok {
+		return false, false
+	}
+	return b.Covered > 0, true
+}
+
+func TestBlockAt(t *testing.T) {
+	blocks := []*profile.Block{
+		{StartLine: 5, StartChar: 2, EndLine: 5, EndChar: 10, Covered: 1},
+		{StartLine: 5, StartChar: 10, EndLine: 6, EndChar: 3, Covered: 0},
+	}
+
+	cases := []struct {
+		name     string
+		line     int
+		col      int
+		wantOK   bool
+		wantHits int
+	}{
+		{"exact match, first block", 5, 2, true, 1},
+		{"exact match, second block", 5, 10, true, 0},
+		{"same line, no block starts here", 5, 3, false, 0},
+		{"no block on this line at all", 6, 3, false, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b, ok := blockAt(blocks, c.line, c.col)
+			if ok != c.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, c.wantOK)
+			}
+			if ok && b.Covered != c.wantHits {
+				t.Fatalf("Covered = %d, want %d", b.Covered, c.wantHits)
+			}
+		})
+	}
+}
+
+func TestCollectBranches(t *testing.T) {
+	blocks := branchProfile(t)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "testdata/branches/branches.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+	arms := collectBranches(fset, f)
+
+	cases := []struct {
+		name         string
+		blockID      int
+		branchID     int
+		wantHasPos   bool
+		wantHasBlock bool
+		wantTaken    bool
+	}{
+		// IfElse's "else if" is itself a nested IfStmt, so ast.Inspect
+		// walks it as its own if/else pair with its own blockID.
+		//
+		// Outer: if n > 10 {big} else if n > 0 {small} else {nonpositive}.
+		{"IfElse outer if", 0, 0, true, true, true},
+		{"IfElse outer else", 0, 1, true, true, true},
+		// Inner (the "else if"): if n > 0 {small} else {nonpositive}.
+		{"IfElse inner if", 1, 0, true, true, true},
+		{"IfElse inner else", 1, 1, true, true, false},
+
+		// Switch: explicit default, only case 1 exercised.
+		{"Switch case one", 2, 0, true, true, true},
+		{"Switch case two", 2, 1, true, true, false},
+		{"Switch default", 2, 2, true, true, false},
+
+		// NoDefaultSwitch: no default clause, so the implicit arm has no
+		// source position and can never be "taken".
+		{"NoDefaultSwitch case one", 3, 0, true, true, false},
+		{"NoDefaultSwitch case two", 3, 1, true, true, false},
+		{"NoDefaultSwitch implicit", 3, 2, false, false, false},
+
+		// Select: explicit default clause, which is the one taken.
+		{"Select comm clause", 4, 0, true, true, false},
+		{"Select default", 4, 1, true, true, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a := arm(t, arms, c.blockID, c.branchID)
+			if a.hasPos != c.wantHasPos {
+				t.Fatalf("hasPos = %v, want %v", a.hasPos, c.wantHasPos)
+			}
+			covered, hasBlock := taken(arms, blocks, a)
+			if hasBlock != c.wantHasBlock {
+				t.Fatalf("hasBlock = %v, want %v", hasBlock, c.wantHasBlock)
+			}
+			if covered != c.wantTaken {
+				t.Fatalf("taken = %v, want %v", covered, c.wantTaken)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Package branches is a fixture exercising if/else, switch and select
+// constructs, used by lcov's TestCollectBranches to check branch
+// positions against a real go test -coverprofile run.
+package branches
+
+// IfElse has a plain if, an else-if and a plain else arm.
+func IfElse(n int) string {
+	if n > 10 {
+		return "big"
+	} else if n > 0 {
+		return "small"
+	} else {
+		return "nonpositive"
+	}
+}
+
+// Switch has an explicit default case.
+func Switch(n int) string {
+	switch {
+	case n == 1:
+		return "one"
+	case n == 2:
+		return "two"
+	default:
+		return "other"
+	}
+}
+
+// NoDefaultSwitch has no default case, so falling through is implicit.
+func NoDefaultSwitch(n int) string {
+	switch {
+	case n == 1:
+		return "one"
+	case n == 2:
+		return "two"
+	}
+	return "other"
+}
+
+// Select has an explicit default clause.
+func Select(ch chan int) string {
+	select {
+	case <-ch:
+		return "received"
+	default:
+		return "empty"
+	}
+}
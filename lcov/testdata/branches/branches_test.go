@@ -0,0 +1,24 @@
+package branches
+
+import "testing"
+
+// These calls exist to produce a predictable, partial go test -coverprofile:
+// some branch arms are taken, others deliberately are not, so
+// lcov.TestCollectBranches can assert on both.
+func TestIfElse(t *testing.T) {
+	IfElse(20) // if arm
+	IfElse(5)  // else-if arm; the plain else arm is never exercised
+}
+
+func TestSwitch(t *testing.T) {
+	Switch(1) // "one" case; "two" and default are never exercised
+}
+
+func TestNoDefaultSwitch(t *testing.T) {
+	NoDefaultSwitch(99) // no case matches, falls through
+}
+
+func TestSelect(t *testing.T) {
+	ch := make(chan int)
+	Select(ch) // nothing sent, so the default clause is taken
+}
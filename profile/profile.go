@@ -0,0 +1,154 @@
+// Package profile parses and merges Go coverage profiles, the format
+// produced by `go test -coverprofile`.
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Mode is the coverage mode declared by a profile's "mode:" header.
+type Mode string
+
+// The coverage modes supported by `go test -covermode`.
+const (
+	ModeSet    Mode = "set"
+	ModeCount  Mode = "count"
+	ModeAtomic Mode = "atomic"
+)
+
+// Block is a single coverage block: the source range it covers, its
+// statement count, and how many times it was hit.
+type Block struct {
+	StartLine  int
+	StartChar  int
+	EndLine    int
+	EndChar    int
+	Statements int
+	Covered    int
+}
+
+// Profile is a parsed Go coverage profile: the declared mode plus the
+// coverage blocks for each source file, keyed by the file path exactly as
+// it appears in the profile, e.g. "github.com/user/repo/pkg/file.go".
+type Profile struct {
+	Mode   Mode
+	Blocks map[string][]*Block
+}
+
+// ParseProfile reads a go cover profile, e.g. one produced by
+// `go test -coverprofile=cover.out`.
+//
+// Format being parsed is:
+//
+//	name.go:line.column,line.column numberOfStatements count
+//
+// e.g.
+//
+//	github.com/jandelgado/golang-ci-template/main.go:6.14,8.2 1 1
+func ParseProfile(r io.Reader) (*Profile, error) {
+	scanner := bufio.NewScanner(r)
+	p := &Profile{Blocks: map[string][]*Block{}}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "mode:") {
+			p.Mode = Mode(strings.TrimSpace(strings.TrimPrefix(line, "mode:")))
+			continue
+		}
+		file, b, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		p.Blocks[file] = append(p.Blocks[file], b)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func parseLine(line string) (string, *Block, bool) {
+	path := strings.Split(line, ":")
+	if len(path) != 2 {
+		return "", nil, false
+	}
+	parts := strings.Split(path[1], " ")
+	if len(parts) != 3 {
+		return "", nil, false
+	}
+	sections := strings.Split(parts[0], ",")
+	if len(sections) != 2 {
+		return "", nil, false
+	}
+	start := strings.Split(sections[0], ".")
+	end := strings.Split(sections[1], ".")
+	if len(start) != 2 || len(end) != 2 {
+		return "", nil, false
+	}
+
+	b := &Block{}
+	b.StartLine, _ = strconv.Atoi(start[0])
+	b.StartChar, _ = strconv.Atoi(start[1])
+	b.EndLine, _ = strconv.Atoi(end[0])
+	b.EndChar, _ = strconv.Atoi(end[1])
+	b.Statements, _ = strconv.Atoi(parts[1])
+	b.Covered, _ = strconv.Atoi(parts[2])
+	return path[0], b, true
+}
+
+// blockKey identifies a block independently of its covered count, so that
+// the same block appearing in several profiles can be merged.
+func blockKey(b *Block) string {
+	return fmt.Sprintf("%d.%d,%d.%d,%d", b.StartLine, b.StartChar, b.EndLine, b.EndChar, b.Statements)
+}
+
+// mergeBlockInto folds src into dst according to the semantics of mode: for
+// ModeSet a block is covered if it is covered in any input, for ModeCount
+// and ModeAtomic the hit counts are summed.
+func mergeBlockInto(mode Mode, dst *Block, src *Block) {
+	if mode == ModeSet {
+		if src.Covered > 0 {
+			dst.Covered = 1
+		}
+		return
+	}
+	dst.Covered += src.Covered
+}
+
+// MergeProfiles merges one or more profiles into a single profile. All
+// profiles that declare a mode must declare the same one.
+func MergeProfiles(profiles ...*Profile) (*Profile, error) {
+	merged := &Profile{Blocks: map[string][]*Block{}}
+	index := map[string]map[string]*Block{}
+
+	for _, p := range profiles {
+		if p.Mode != "" {
+			if merged.Mode == "" {
+				merged.Mode = p.Mode
+			} else if merged.Mode != p.Mode {
+				return nil, fmt.Errorf("cannot merge coverage profiles: mode %q does not match %q", p.Mode, merged.Mode)
+			}
+		}
+
+		for file, blocks := range p.Blocks {
+			if _, ok := index[file]; !ok {
+				index[file] = map[string]*Block{}
+			}
+			for _, b := range blocks {
+				key := blockKey(b)
+				if existing, ok := index[file][key]; ok {
+					mergeBlockInto(merged.Mode, existing, b)
+					continue
+				}
+				nb := *b
+				index[file][key] = &nb
+				merged.Blocks[file] = append(merged.Blocks[file], &nb)
+			}
+		}
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,76 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, text string) *Profile {
+	t.Helper()
+	p, err := ParseProfile(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("ParseProfile: %v", err)
+	}
+	return p
+}
+
+func TestMergeProfilesModeMismatch(t *testing.T) {
+	a := mustParse(t, "mode: set\nfile.go:1.1,2.2 1 1\n")
+	b := mustParse(t, "mode: count\nfile.go:1.1,2.2 1 1\n")
+
+	_, err := MergeProfiles(a, b)
+	if err == nil {
+		t.Fatal("expected an error merging mismatched modes, got nil")
+	}
+	if !strings.Contains(err.Error(), `"count"`) || !strings.Contains(err.Error(), `"set"`) {
+		t.Fatalf("error %q doesn't name both modes", err)
+	}
+}
+
+func TestMergeProfilesSetOR(t *testing.T) {
+	a := mustParse(t, "mode: set\nfile.go:1.1,2.2 1 1\nfile.go:3.1,4.2 1 0\n")
+	b := mustParse(t, "mode: set\nfile.go:1.1,2.2 1 0\nfile.go:3.1,4.2 1 0\n")
+
+	merged, err := MergeProfiles(a, b)
+	if err != nil {
+		t.Fatalf("MergeProfiles: %v", err)
+	}
+	if merged.Mode != ModeSet {
+		t.Fatalf("Mode = %q, want %q", merged.Mode, ModeSet)
+	}
+
+	blocks := merged.Blocks["file.go"]
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2", len(blocks))
+	}
+	for _, b := range blocks {
+		want := 0
+		if b.StartLine == 1 {
+			want = 1 // covered in a, so the OR must stay covered
+		}
+		if b.Covered != want {
+			t.Errorf("block at %d.%d: Covered = %d, want %d", b.StartLine, b.StartChar, b.Covered, want)
+		}
+	}
+}
+
+func TestMergeProfilesCountSum(t *testing.T) {
+	a := mustParse(t, "mode: count\nfile.go:1.1,2.2 1 3\n")
+	b := mustParse(t, "mode: count\nfile.go:1.1,2.2 1 4\n")
+
+	merged, err := MergeProfiles(a, b)
+	if err != nil {
+		t.Fatalf("MergeProfiles: %v", err)
+	}
+	if merged.Mode != ModeCount {
+		t.Fatalf("Mode = %q, want %q", merged.Mode, ModeCount)
+	}
+
+	blocks := merged.Blocks["file.go"]
+	if len(blocks) != 1 {
+		t.Fatalf("got %d blocks, want 1", len(blocks))
+	}
+	if got, want := blocks[0].Covered, 7; got != want {
+		t.Fatalf("Covered = %d, want %d", got, want)
+	}
+}